@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFileBackendRoundTrip(t *testing.T) {
+	b := &fileBackend{root: t.TempDir()}
+
+	if err := b.Put("/docs/readme.txt", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	list, err := b.List("/docs/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 1 || list[0].name != "docs/readme.txt" || list[0].isDir {
+		t.Fatalf("List(/docs/) = %+v, want a single readme.txt entry", list)
+	}
+
+	info, err := b.Stat("/docs/readme.txt")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.ContentLength != 5 {
+		t.Fatalf("Stat ContentLength = %d, want 5", info.ContentLength)
+	}
+
+	rc, err := b.Open("/docs/readme.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("reading opened object: %v", err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("Open content = %q, want %q", data, "hello")
+	}
+
+	if err := b.Delete("/docs/readme.txt"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := b.Stat("/docs/readme.txt"); err == nil {
+		t.Fatal("expected Stat to fail after Delete")
+	}
+}
+
+func TestFileBackendOpenRange(t *testing.T) {
+	b := &fileBackend{root: t.TempDir()}
+	if err := b.Put("/blob", strings.NewReader("0123456789"), 10, ""); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	rc, info, err := b.OpenRange("/blob", "bytes=2-4")
+	if err != nil {
+		t.Fatalf("OpenRange: %v", err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("reading ranged object: %v", err)
+	}
+	if string(data) != "234" {
+		t.Fatalf("ranged content = %q, want %q", data, "234")
+	}
+	if info.ContentRange != "bytes 2-4/10" {
+		t.Fatalf("ContentRange = %q, want %q", info.ContentRange, "bytes 2-4/10")
+	}
+}
+
+func TestRouterResolve(t *testing.T) {
+	hostBackend := &fileBackend{root: t.TempDir()}
+	prefixBackend := &fileBackend{root: t.TempDir()}
+	router := &Router{routes: []route{
+		{host: "docs.example.com", backend: hostBackend},
+		{pathPrefix: "assets", backend: prefixBackend},
+	}}
+
+	t.Run("host match", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://docs.example.com/readme.txt", nil)
+		backend, root, path, ok := router.Resolve(req)
+		if !ok || backend != Backend(hostBackend) || root != "" || path != "/readme.txt" {
+			t.Fatalf("Resolve(host) = (%v, %q, %q, %v), want (hostBackend, \"\", \"/readme.txt\", true)", backend, root, path, ok)
+		}
+	})
+
+	t.Run("path prefix match", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://gateway.internal/assets/logo.png", nil)
+		backend, root, path, ok := router.Resolve(req)
+		if !ok || backend != Backend(prefixBackend) || root != "/assets" || path != "/logo.png" {
+			t.Fatalf("Resolve(prefix) = (%v, %q, %q, %v), want (prefixBackend, \"/assets\", \"/logo.png\", true)", backend, root, path, ok)
+		}
+	})
+
+	t.Run("path prefix root itself", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://gateway.internal/assets", nil)
+		_, root, path, ok := router.Resolve(req)
+		if !ok || root != "/assets" || path != "/" {
+			t.Fatalf("Resolve(prefix root) = (%q, %q, %v), want (\"/assets\", \"/\", true)", root, path, ok)
+		}
+	})
+
+	t.Run("no match falls through", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://gateway.internal/unknown/file.txt", nil)
+		_, _, _, ok := router.Resolve(req)
+		if ok {
+			t.Fatal("expected no route to match an unconfigured host/prefix")
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parseByteRange parses a single-range "bytes=start-end" Range header value
+// against an object of the given size. It only handles the first range in a
+// multi-range header, which covers every client the gateway needs to proxy
+// for. ok is false when the header is absent or malformed, in which case the
+// caller should serve the whole object.
+func parseByteRange(header string, size int64) (start, end int64, ok bool) {
+	if !strings.HasPrefix(header, "bytes=") {
+		return 0, size - 1, false
+	}
+	spec := strings.SplitN(strings.TrimPrefix(header, "bytes="), ",", 2)[0]
+	parts := strings.SplitN(spec, "-", 2)
+	if len(parts) != 2 {
+		return 0, size - 1, false
+	}
+	if parts[0] == "" {
+		suffixLen, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || suffixLen <= 0 {
+			return 0, size - 1, false
+		}
+		start = size - suffixLen
+		if start < 0 {
+			start = 0
+		}
+		return start, size - 1, true
+	}
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, size - 1, false
+	}
+	end = size - 1
+	if parts[1] != "" {
+		end, err = strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return 0, size - 1, false
+		}
+	}
+	if end >= size {
+		end = size - 1
+	}
+	if end < start {
+		return 0, size - 1, false
+	}
+	return start, end, true
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// loadS3Credentials reads a JSON file mapping access key ID to secret access
+// key, so tenants can be handed S3-shaped credentials without ever seeing the
+// real keys backing the gateway's bucket. An empty path yields no
+// credentials, which disables the S3 API surface entirely.
+func loadS3Credentials(path string) (map[string]string, error) {
+	if path == "" {
+		return map[string]string{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read S3 credentials file: %v", err)
+	}
+	creds := map[string]string{}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("failed to parse S3 credentials file: %v", err)
+	}
+	return creds, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterBackend("file", newFileBackendFromURL)
+}
+
+// fileBackend serves a local directory tree as though it were a bucket,
+// mainly useful for local development and for exercising the HTTP layer in
+// tests without standing up real S3.
+type fileBackend struct {
+	root string
+}
+
+func newFileBackendFromURL(config *url.URL) (Backend, error) {
+	root := config.Path
+	if root == "" {
+		return nil, fmt.Errorf("file backend requires a path, e.g. file:///srv/data")
+	}
+	return &fileBackend{root: filepath.Clean(root)}, nil
+}
+
+func (b *fileBackend) resolve(key string) string {
+	return filepath.Join(b.root, filepath.Clean("/"+strings.TrimPrefix(key, "/")))
+}
+
+func (b *fileBackend) List(prefix string) ([]file, error) {
+	entries, err := os.ReadDir(b.resolve(prefix))
+	if err != nil {
+		return nil, err
+	}
+	key := strings.TrimPrefix(prefix, "/")
+	files := make([]file, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			files = append(files, file{name: key + entry.Name() + "/", isDir: true})
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file{
+			name:         key + entry.Name(),
+			size:         info.Size(),
+			lastModified: info.ModTime(),
+		})
+	}
+	return files, nil
+}
+
+// PresignGet has no real notion of a presigned URL for a local filesystem, so
+// it hands back a same-origin path for buildHandler's existing redirect flow
+// to follow back into this backend.
+func (b *fileBackend) PresignGet(key string, _ time.Duration) (string, error) {
+	return "/" + strings.TrimPrefix(key, "/"), nil
+}
+
+func (b *fileBackend) Open(key string) (io.ReadCloser, error) {
+	return os.Open(b.resolve(key))
+}
+
+func (b *fileBackend) Stat(key string) (ObjectInfo, error) {
+	info, err := os.Stat(b.resolve(key))
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{
+		ContentType:   mime.TypeByExtension(filepath.Ext(key)),
+		ContentLength: info.Size(),
+		ETag:          fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size()),
+		LastModified:  info.ModTime(),
+	}, nil
+}
+
+func (b *fileBackend) OpenRange(key, rangeHeader string) (io.ReadCloser, ObjectInfo, error) {
+	info, err := b.Stat(key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	f, err := os.Open(b.resolve(key))
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	if rangeHeader == "" {
+		return f, info, nil
+	}
+	start, end, ok := parseByteRange(rangeHeader, info.ContentLength)
+	if !ok {
+		return f, info, nil
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, ObjectInfo{}, err
+	}
+	info.ContentRange = fmt.Sprintf("bytes %d-%d/%d", start, end, info.ContentLength)
+	info.ContentLength = end - start + 1
+	return &limitedFile{f: f, r: io.LimitReader(f, info.ContentLength)}, info, nil
+}
+
+// limitedFile caps reads to a byte range while still closing the underlying
+// file handle, since io.LimitReader on its own drops Close.
+type limitedFile struct {
+	f *os.File
+	r io.Reader
+}
+
+func (l *limitedFile) Read(p []byte) (int, error) { return l.r.Read(p) }
+func (l *limitedFile) Close() error                { return l.f.Close() }
+
+// Put ignores contentType: fileBackend has nowhere to persist it, so Stat
+// derives it from key's extension on every read instead.
+func (b *fileBackend) Put(key string, data io.Reader, _ int64, _ string) error {
+	path := b.resolve(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, data)
+	return err
+}
+
+func (b *fileBackend) Delete(key string) error {
+	return os.Remove(b.resolve(key))
+}
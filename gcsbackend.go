@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+func init() {
+	RegisterBackend("gcs", newGCSBackendFromURL)
+}
+
+// gcsBackend fronts a Google Cloud Storage bucket, giving the gateway the
+// same interface as the S3 and local-disk backends.
+type gcsBackend struct {
+	client *storage.Client
+	bucket string
+}
+
+func newGCSBackendFromURL(config *url.URL) (Backend, error) {
+	bucket := config.Host
+	if bucket == "" {
+		return nil, fmt.Errorf("gcs backend requires a bucket, e.g. gcs://bucket")
+	}
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %v", err)
+	}
+	return &gcsBackend{client: client, bucket: bucket}, nil
+}
+
+func (b *gcsBackend) List(prefix string) ([]file, error) {
+	it := b.client.Bucket(b.bucket).Objects(context.Background(), &storage.Query{
+		Prefix:    strings.TrimPrefix(prefix, "/"),
+		Delimiter: "/",
+	})
+	var files []file
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if attrs.Prefix != "" {
+			files = append(files, file{name: attrs.Prefix, isDir: true})
+			continue
+		}
+		files = append(files, file{
+			name:         attrs.Name,
+			size:         attrs.Size,
+			lastModified: attrs.Updated,
+		})
+	}
+	return files, nil
+}
+
+func (b *gcsBackend) PresignGet(key string, ttl time.Duration) (string, error) {
+	return storage.SignedURL(b.bucket, strings.TrimPrefix(key, "/"), &storage.SignedURLOptions{
+		GoogleAccessID: os.Getenv("GCS_ACCESS_ID"),
+		PrivateKey:     []byte(os.Getenv("GCS_PRIVATE_KEY")),
+		Method:         http.MethodGet,
+		Expires:        time.Now().Add(ttl),
+	})
+}
+
+func (b *gcsBackend) Open(key string) (io.ReadCloser, error) {
+	return b.client.Bucket(b.bucket).Object(strings.TrimPrefix(key, "/")).NewReader(context.Background())
+}
+
+func (b *gcsBackend) Stat(key string) (ObjectInfo, error) {
+	attrs, err := b.client.Bucket(b.bucket).Object(strings.TrimPrefix(key, "/")).Attrs(context.Background())
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{
+		ContentType:   attrs.ContentType,
+		ContentLength: attrs.Size,
+		ETag:          attrs.Etag,
+		LastModified:  attrs.Updated,
+	}, nil
+}
+
+func (b *gcsBackend) OpenRange(key, rangeHeader string) (io.ReadCloser, ObjectInfo, error) {
+	info, err := b.Stat(key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	obj := b.client.Bucket(b.bucket).Object(strings.TrimPrefix(key, "/"))
+	if rangeHeader == "" {
+		r, err := obj.NewReader(context.Background())
+		return r, info, err
+	}
+	start, end, ok := parseByteRange(rangeHeader, info.ContentLength)
+	if !ok {
+		r, err := obj.NewReader(context.Background())
+		return r, info, err
+	}
+	r, err := obj.NewRangeReader(context.Background(), start, end-start+1)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	info.ContentRange = fmt.Sprintf("bytes %d-%d/%d", start, end, info.ContentLength)
+	info.ContentLength = end - start + 1
+	return r, info, nil
+}
+
+func (b *gcsBackend) Put(key string, data io.Reader, _ int64, contentType string) error {
+	w := b.client.Bucket(b.bucket).Object(strings.TrimPrefix(key, "/")).NewWriter(context.Background())
+	w.ContentType = contentType
+	if _, err := io.Copy(w, data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (b *gcsBackend) Delete(key string) error {
+	return b.client.Bucket(b.bucket).Object(strings.TrimPrefix(key, "/")).Delete(context.Background())
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// listingEntry is the shared representation of one directory entry used by
+// both the HTML and JSON renderers, so presigning only happens in one place.
+type listingEntry struct {
+	name         string
+	isDir        bool
+	size         int64
+	lastModified time.Time
+	url          string
+}
+
+// buildListingEntries sorts list and resolves each entry's URL: a root-
+// relative path for directories, a presigned link for files.
+func buildListingEntries(backend Backend, root string, list []file) ([]listingEntry, error) {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].name < list[j].name
+	})
+	entries := make([]listingEntry, 0, len(list))
+	for _, f := range list {
+		url := fmt.Sprintf("%s/%s", root, f.name)
+		if !f.isDir {
+			var err error
+			url, err = backend.PresignGet(f.name, time.Minute*5)
+			if err != nil {
+				return nil, fmt.Errorf("error getting presigned link for %s: %v", f.name, err)
+			}
+		}
+		entries = append(entries, listingEntry{
+			name:         f.name,
+			isDir:        f.isDir,
+			size:         f.size,
+			lastModified: f.lastModified,
+			url:          url,
+		})
+	}
+	return entries, nil
+}
+
+// wantsJSONListing reports whether a directory listing should be served as
+// JSON instead of HTML, so scripts (curl/jq, CI pipelines) can enumerate a
+// bucket without HTML-scraping.
+func wantsJSONListing(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "json" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+type jsonListingEntry struct {
+	Name         string `json:"name"`
+	IsDir        bool   `json:"isDir"`
+	Size         int64  `json:"size,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	URL          string `json:"url,omitempty"`
+}
+
+type jsonListing struct {
+	Path    string             `json:"path"`
+	Entries []jsonListingEntry `json:"entries"`
+}
+
+func renderFileListJSON(backend Backend, w http.ResponseWriter, root, path string, list []file) error {
+	entries, err := buildListingEntries(backend, root, list)
+	if err != nil {
+		return err
+	}
+	jsonEntries := make([]jsonListingEntry, 0, len(entries))
+	for _, e := range entries {
+		entry := jsonListingEntry{Name: e.name, IsDir: e.isDir}
+		if !e.isDir {
+			entry.Size = e.size
+			entry.LastModified = e.lastModified.Format(time.RFC3339)
+			entry.URL = e.url
+		}
+		jsonEntries = append(jsonEntries, entry)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(jsonListing{Path: path, Entries: jsonEntries})
+}
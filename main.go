@@ -3,15 +3,10 @@ package main
 import (
 	"bytes"
 	"fmt"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"log"
 	"net/http"
 	"os"
-	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -52,139 +47,160 @@ func withLogging(h http.Handler) http.HandlerFunc {
 	}
 }
 
-type s3Client struct {
-	s3iface.S3API
-	bucket string
-}
-
-func (c *s3Client) listObjectsByPrefix(path string) ([]file, error) {
-	var (
-		continuationToken *string
-		files             []file
-		key               = strings.TrimPrefix(path, "/")
-	)
-	for {
-		listObjectsV2Output, err := c.S3API.ListObjectsV2(&s3.ListObjectsV2Input{
-			Bucket:            aws.String(c.bucket),
-			Delimiter:         aws.String("/"),
-			Prefix:            aws.String(key),
-			ContinuationToken: continuationToken,
-		})
-		if err != nil {
-			return nil, err
-		}
-		for _, object := range listObjectsV2Output.Contents {
-			files = append(files, file{
-				name:         aws.StringValue(object.Key),
-				size:         aws.Int64Value(object.Size),
-				lastModified: aws.TimeValue(object.LastModified),
-			})
-		}
-		for _, prefix := range listObjectsV2Output.CommonPrefixes {
-			files = append(files, file{
-				name:  aws.StringValue(prefix.Prefix),
-				isDir: true,
-			})
-		}
-		if !aws.BoolValue(listObjectsV2Output.IsTruncated) || listObjectsV2Output.NextContinuationToken == nil {
-			break
-		}
-		continuationToken = listObjectsV2Output.NextContinuationToken
+// renderFileList writes the HTML listing for list. root is the URL prefix
+// the resolved backend is mounted at (empty for the default, unrouted
+// backend) and path is the request path with that root already stripped, so
+// breadcrumbs render relative to the bucket root rather than the gateway's
+// full URL space; root is re-added when building hrefs so links still
+// navigate back through the router. When writes is enabled, an upload form
+// and per-file delete buttons are appended.
+func renderFileList(backend Backend, w http.ResponseWriter, root, path string, list []file, writes writeConfig) error {
+	entries, err := buildListingEntries(backend, root, list)
+	if err != nil {
+		return err
 	}
-	return files, nil
-}
-
-func (c *s3Client) getPreSignedLink(key string, duration time.Duration) (string, error) {
-	req, _ := c.GetObjectRequest(&s3.GetObjectInput{
-		Bucket: aws.String(c.bucket),
-		Key:    aws.String(key),
-	})
-	return req.Presign(duration)
-}
-
-type file struct {
-	name         string
-	isDir        bool
-	size         int64
-	lastModified time.Time
-}
-
-func (c *s3Client) renderFileList(w http.ResponseWriter, r *http.Request, list []file) (err error) {
-	sort.Slice(list, func(i, j int) bool {
-		return list[i].name < list[j].name
-	})
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	_, _ = fmt.Fprintf(w, "<pre>\n")
-	_, _ = fmt.Fprintf(w, "%s\n\n", r.URL.Path)
-	if r.URL.Path != "/" {
-		path := r.URL.Path[:strings.LastIndex(r.URL.Path, "/")]
-		_, _ = fmt.Fprintf(w, "<a href=\"%s/..\">..</a>\n", path)
+	_, _ = fmt.Fprintf(w, "%s\n\n", path)
+	if path != "/" {
+		parent := path[:strings.LastIndex(path, "/")]
+		_, _ = fmt.Fprintf(w, "<a href=\"%s%s/..\">..</a>\n", root, parent)
 	}
 	buf := bytes.NewBuffer(nil)
 	tab := tabwriter.NewWriter(buf, 0, 0, 1, ' ', 0)
-	for _, dir := range list {
-		if dir.isDir {
-			_, _ = fmt.Fprintf(tab, "%s\t\t\t\n", dir.name)
+	for _, e := range entries {
+		if e.isDir {
+			_, _ = fmt.Fprintf(tab, "%s\t\t\t\n", e.name)
 		} else {
-			_, _ = fmt.Fprintf(tab, "%s\t%s\t%s\n", dir.name, dir.lastModified.Format(time.RFC3339), humanizeBytes(dir.size))
+			_, _ = fmt.Fprintf(tab, "%s\t%s\t%s\n", e.name, e.lastModified.Format(time.RFC3339), humanizeBytes(e.size))
 		}
 	}
 	_ = tab.Flush()
 	content := buf.String()
-	for _, dir := range list {
-		link := ""
-		if dir.isDir {
-			link = fmt.Sprintf("/%s", dir.name)
-		} else {
-			link, err = c.getPreSignedLink(dir.name, time.Minute*5)
-			if err != nil {
-				return fmt.Errorf("error getting presigned link for %s: %v", dir.name, err)
-			}
+	for _, e := range entries {
+		anchor := fmt.Sprintf("<a href=\"%s\">%s</a>", e.url, e.name)
+		if writes.enabled && !e.isDir {
+			anchor += deleteButtonHTML(root, e.name)
 		}
-		content = strings.Replace(content, dir.name, fmt.Sprintf("<a href=\"%s\">%s</a>", link, dir.name), 1)
+		content = strings.Replace(content, e.name, anchor, 1)
 	}
 	_, _ = fmt.Fprint(w, content, "\n</pre>\n")
+	if writes.enabled {
+		_, _ = fmt.Fprint(w, uploadFormHTML)
+	}
 	return nil
 }
 
-func buildHandler(client *s3Client) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		path := r.URL.Path
-		if strings.HasSuffix(path, "/") {
-			list, err := client.listObjectsByPrefix(path)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			if err = client.renderFileList(w, r, list); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-			}
+// serveBackend handles a single browsing or write request against backend,
+// with path already resolved relative to root (the URL prefix backend is
+// mounted at). When cache is non-nil, file GETs/HEADs are streamed
+// in-process through the proxy instead of redirecting to a presigned URL.
+func serveBackend(backend Backend, root, path string, cache *objectCache, writes writeConfig, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut:
+		handlePut(backend, path, writes, w, r)
+		return
+	case http.MethodPost:
+		handlePost(backend, root, path, writes, w, r)
+		return
+	case http.MethodDelete:
+		handleDelete(backend, root, path, writes, w, r)
+		return
+	}
+	if strings.HasSuffix(path, "/") {
+		list, err := backend.List(path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
+		}
+		if wantsJSONListing(r) {
+			err = renderFileListJSON(backend, w, root, path, list)
 		} else {
-			link, err := client.getPreSignedLink(path, time.Minute*5)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			http.Redirect(w, r, link, http.StatusPermanentRedirect)
+			err = renderFileList(backend, w, root, path, list, writes)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if cache != nil {
+		proxyObject(backend, path, cache, w, r)
+		return
+	}
+	link, err := backend.PresignGet(path, time.Minute*5)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, link, http.StatusPermanentRedirect)
+}
+
+func buildHandler(backend Backend, cache *objectCache, writes writeConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serveBackend(backend, "", r.URL.Path, cache, writes, w, r)
+	}
+}
+
+// buildRoutedHandler consults router for each request's Host or first path
+// segment before falling back to defaultBackend, so a single gateway process
+// can front many buckets across accounts.
+func buildRoutedHandler(router *Router, defaultBackend Backend, cache *objectCache, writes writeConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		backend, root, path, ok := router.Resolve(r)
+		if !ok {
+			backend, root, path = defaultBackend, "", r.URL.Path
 		}
+		serveBackend(backend, root, path, cache, writes, w, r)
 	}
 }
 
+// backendConfig returns the URL-style backend config string, falling back to
+// the legacy S3_BUCKET_NAME variable so existing deployments keep working.
+func backendConfig() string {
+	if config := os.Getenv("S3_BACKEND"); config != "" {
+		return config
+	}
+	return fmt.Sprintf("s3://%s", os.Getenv("S3_BUCKET_NAME"))
+}
+
+// proxyCacheBytes reads the PROXY_CACHE_BYTES size limit for the streaming
+// proxy's object cache, defaulting to 64MB.
+func proxyCacheBytes() int64 {
+	const defaultCacheBytes = 64 * 1 << 20
+	raw := os.Getenv("PROXY_CACHE_BYTES")
+	if raw == "" {
+		return defaultCacheBytes
+	}
+	parsed, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || parsed <= 0 {
+		return defaultCacheBytes
+	}
+	return parsed
+}
+
 func main() {
-	bucketName := os.Getenv("S3_BUCKET_NAME")
-	sess, err := session.NewSession(&aws.Config{
-		Region:      aws.String("us-east-1"),
-		Endpoint:    aws.String(os.Getenv("S3_ENDPOINT")),
-		Credentials: credentials.NewStaticCredentials(os.Getenv("S3_ACCESS_KEY_ID"), os.Getenv("S3_SECRET_ACCESS_KEY"), ""),
-	})
+	backend, err := NewBackend(backendConfig())
 	if err != nil {
-		log.Fatal("failed to create AWS session: ", err)
+		log.Fatal("failed to initialize backend: ", err)
+	}
+	creds, err := loadS3Credentials(os.Getenv("S3_CREDENTIALS_FILE"))
+	if err != nil {
+		log.Fatal("failed to load S3 credentials: ", err)
+	}
+	var router *Router
+	if routerConfigPath := os.Getenv("ROUTER_CONFIG"); routerConfigPath != "" {
+		router, err = NewRouter(routerConfigPath)
+		if err != nil {
+			log.Fatal("failed to load router config: ", err)
+		}
 	}
-	client := &s3Client{
-		S3API:  s3.New(sess),
-		bucket: bucketName,
+	var cache *objectCache
+	if os.Getenv("PROXY_OBJECTS") == "true" {
+		cache = newObjectCache(proxyCacheBytes())
 	}
-	http.Handle("/", withLogging(buildHandler(client)))
+	writes := loadWriteConfig()
+	client, _ := backend.(*s3Client)
+	handler := newS3Handler(backend, router, client, creds, writes.enabled, cache, writes)
+	http.Handle("/", withLogging(handler))
 	log.Fatal("failed to serve request: ", http.ListenAndServe(":8080", nil))
 }
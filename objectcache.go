@@ -0,0 +1,80 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// objectCache is a small LRU byte cache keyed by (key, etag, range), so hot
+// objects served through the streaming proxy don't round-trip to the backend
+// on every request.
+type objectCache struct {
+	mu        sync.Mutex
+	ll        *list.List
+	items     map[string]*list.Element
+	maxBytes  int64
+	usedBytes int64
+}
+
+type cacheEntry struct {
+	key  string
+	data []byte
+}
+
+func newObjectCache(maxBytes int64) *objectCache {
+	return &objectCache{
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+		maxBytes: maxBytes,
+	}
+}
+
+func objectCacheKey(key, etag, rangeHeader string) string {
+	return fmt.Sprintf("%s\x00%s\x00%s", key, etag, rangeHeader)
+}
+
+// MaxBytes reports the cache's byte budget, so callers can decide whether an
+// object is even a candidate for caching before reading its body.
+func (c *objectCache) MaxBytes() int64 {
+	return c.maxBytes
+}
+
+func (c *objectCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).data, true
+}
+
+func (c *objectCache) Add(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if int64(len(data)) > c.maxBytes {
+		return
+	}
+	if elem, ok := c.items[key]; ok {
+		c.usedBytes -= int64(len(elem.Value.(*cacheEntry).data))
+		c.ll.MoveToFront(elem)
+		elem.Value.(*cacheEntry).data = data
+		c.usedBytes += int64(len(data))
+	} else {
+		elem := c.ll.PushFront(&cacheEntry{key: key, data: data})
+		c.items[key] = elem
+		c.usedBytes += int64(len(data))
+	}
+	for c.usedBytes > c.maxBytes {
+		back := c.ll.Back()
+		if back == nil {
+			break
+		}
+		entry := back.Value.(*cacheEntry)
+		c.usedBytes -= int64(len(entry.data))
+		c.ll.Remove(back)
+		delete(c.items, entry.key)
+	}
+}
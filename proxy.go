@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// proxyObject streams an object's body through the gateway instead of
+// redirecting to a presigned URL, so clients that don't follow redirects
+// still work, HTTP caching isn't defeated, and the backing bucket's hostname
+// never leaks to the client.
+func proxyObject(backend Backend, key string, cache *objectCache, w http.ResponseWriter, r *http.Request) {
+	info, err := backend.Stat(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if notModified(r, info) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if r.Method == http.MethodHead {
+		writeObjectHeaders(w, info)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	rangeHeader := r.Header.Get("Range")
+	key2 := objectCacheKey(key, info.ETag, rangeHeader)
+	if data, ok := cache.Get(key2); ok {
+		writeObjectHeaders(w, info)
+		status := http.StatusOK
+		if rangeHeader != "" {
+			status = http.StatusPartialContent
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write(data)
+		return
+	}
+
+	body, rangedInfo, err := backend.OpenRange(key, rangeHeader)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer body.Close()
+
+	writeObjectHeaders(w, rangedInfo)
+	status := http.StatusOK
+	if rangedInfo.ContentRange != "" {
+		status = http.StatusPartialContent
+	}
+	w.WriteHeader(status)
+
+	if rangedInfo.ContentLength > cache.MaxBytes() {
+		_, _ = io.Copy(w, body)
+		return
+	}
+	var buf bytes.Buffer
+	_, _ = io.Copy(io.MultiWriter(w, &buf), body)
+	cache.Add(key2, buf.Bytes())
+}
+
+func notModified(r *http.Request, info ObjectInfo) bool {
+	if etag := r.Header.Get("If-None-Match"); etag != "" {
+		return etag == info.ETag
+	}
+	if since := r.Header.Get("If-Modified-Since"); since != "" {
+		if t, err := http.ParseTime(since); err == nil {
+			return !info.LastModified.After(t)
+		}
+	}
+	return false
+}
+
+func writeObjectHeaders(w http.ResponseWriter, info ObjectInfo) {
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(info.ContentLength, 10))
+	w.Header().Set("ETag", info.ETag)
+	w.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Accept-Ranges", "bytes")
+	if info.ContentRange != "" {
+		w.Header().Set("Content-Range", info.ContentRange)
+	}
+}
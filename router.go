@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RouteConfig describes one entry in the router config file. A route matches
+// either Host (virtual-hosted style, e.g. "docs.example.com") or PathPrefix
+// (e.g. "docs" for requests under "/docs/..."), and resolves to its own
+// Backend, optionally with its own S3 endpoint and credentials so routes can
+// span buckets in different accounts.
+type RouteConfig struct {
+	Host            string `json:"host,omitempty" yaml:"host,omitempty"`
+	PathPrefix      string `json:"pathPrefix,omitempty" yaml:"pathPrefix,omitempty"`
+	Backend         string `json:"backend" yaml:"backend"`
+	Endpoint        string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	AccessKeyID     string `json:"accessKeyId,omitempty" yaml:"accessKeyId,omitempty"`
+	SecretAccessKey string `json:"secretAccessKey,omitempty" yaml:"secretAccessKey,omitempty"`
+}
+
+type route struct {
+	host       string
+	pathPrefix string
+	backend    Backend
+}
+
+// Router maps an incoming request's Host header or first path segment to the
+// backend for that bucket, so one gateway process can serve many buckets
+// across accounts instead of being bound to a single bucket at startup.
+type Router struct {
+	routes []route
+}
+
+// NewRouter loads a YAML or JSON router config file (selected by the
+// ".yaml"/".yml" extension, JSON otherwise) and resolves each route's
+// backend.
+func NewRouter(configPath string) (*Router, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read router config: %v", err)
+	}
+	var configs []RouteConfig
+	if strings.HasSuffix(configPath, ".yaml") || strings.HasSuffix(configPath, ".yml") {
+		err = yaml.Unmarshal(data, &configs)
+	} else {
+		err = json.Unmarshal(data, &configs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse router config: %v", err)
+	}
+	routes := make([]route, 0, len(configs))
+	for _, c := range configs {
+		if c.Host == "" && c.PathPrefix == "" {
+			return nil, fmt.Errorf("route for backend %q needs a host or pathPrefix", c.Backend)
+		}
+		backend, err := resolveRouteBackend(c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve backend for route %q/%q: %v", c.Host, c.PathPrefix, err)
+		}
+		routes = append(routes, route{
+			host:       c.Host,
+			pathPrefix: strings.Trim(c.PathPrefix, "/"),
+			backend:    backend,
+		})
+	}
+	return &Router{routes: routes}, nil
+}
+
+// resolveRouteBackend builds a route's Backend. S3 routes that specify their
+// own endpoint or credentials bypass the global env-based s3 factory so each
+// route can point at a different account.
+func resolveRouteBackend(c RouteConfig) (Backend, error) {
+	parsed, err := url.Parse(c.Backend)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backend %q: %v", c.Backend, err)
+	}
+	if parsed.Scheme == "s3" && (c.Endpoint != "" || c.AccessKeyID != "") {
+		return newS3BackendWithCredentials(parsed.Host, c.Endpoint, c.AccessKeyID, c.SecretAccessKey)
+	}
+	return NewBackend(c.Backend)
+}
+
+// Resolve finds the route matching r, preferring a Host match (virtual-hosted
+// style) over a path-prefix match. path is returned with root already
+// stripped, so callers can hand it straight to Backend.List/PresignGet.
+func (router *Router) Resolve(r *http.Request) (backend Backend, root, path string, ok bool) {
+	host := r.Host
+	if i := strings.IndexByte(host, ':'); i != -1 {
+		host = host[:i]
+	}
+	for _, rt := range router.routes {
+		if rt.host != "" && rt.host == host {
+			return rt.backend, "", r.URL.Path, true
+		}
+	}
+	trimmed := strings.TrimPrefix(r.URL.Path, "/")
+	for _, rt := range router.routes {
+		if rt.pathPrefix == "" {
+			continue
+		}
+		if trimmed == rt.pathPrefix {
+			return rt.backend, "/" + rt.pathPrefix, "/", true
+		}
+		if strings.HasPrefix(trimmed, rt.pathPrefix+"/") {
+			return rt.backend, "/" + rt.pathPrefix, strings.TrimPrefix(r.URL.Path, "/"+rt.pathPrefix), true
+		}
+	}
+	return nil, "", "", false
+}
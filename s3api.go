@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+type Owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type Bucket struct {
+	Name         string    `xml:"Name"`
+	CreationDate time.Time `xml:"CreationDate"`
+}
+
+type ListAllMyBucketsResult struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListAllMyBucketsResult"`
+	Owner   Owner    `xml:"Owner"`
+	Buckets []Bucket `xml:"Buckets>Bucket"`
+}
+
+type Object struct {
+	Key          string    `xml:"Key"`
+	LastModified time.Time `xml:"LastModified"`
+	Size         int64     `xml:"Size"`
+	StorageClass string    `xml:"StorageClass"`
+}
+
+// CommonPrefix marshals as its own <CommonPrefixes><Prefix> pair, and is
+// only ever emitted when there's at least one prefix to report.
+type CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// ListBucketResult covers both the ListObjectsV2 and legacy V1 responses;
+// the fields unused by one version are simply omitted from the request and
+// left zero-valued in the response.
+type ListBucketResult struct {
+	XMLName               xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	Marker                string         `xml:"Marker,omitempty"`
+	NextMarker            string         `xml:"NextMarker,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	KeyCount              int            `xml:"KeyCount,omitempty"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []Object       `xml:"Contents"`
+	CommonPrefixes        []CommonPrefix `xml:"CommonPrefixes"`
+}
+
+type s3Error struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource"`
+	RequestID string   `xml:"RequestId"`
+}
+
+// s3Handler multiplexes between the browser-facing HTML/redirect handler and
+// the native S3 REST protocol, picking the latter whenever a request looks
+// like it came from an S3 SDK. client is the default, unrouted backend and
+// is nil without an S3 backend; router, if set, picks a per-bucket client
+// the same way buildRoutedHandler does for browser requests.
+type s3Handler struct {
+	client        *s3Client
+	router        *Router
+	browser       http.HandlerFunc
+	creds         map[string]string
+	writesEnabled bool
+}
+
+func newS3Handler(backend Backend, router *Router, client *s3Client, creds map[string]string, writesEnabled bool, cache *objectCache, browserWrites writeConfig) *s3Handler {
+	browser := buildHandler(backend, cache, browserWrites)
+	if router != nil {
+		browser = buildRoutedHandler(router, backend, cache, browserWrites)
+	}
+	return &s3Handler{
+		client:        client,
+		router:        router,
+		browser:       browser,
+		creds:         creds,
+		writesEnabled: writesEnabled,
+	}
+}
+
+// resolveClient picks the *s3Client for r's route, falling back to the
+// default backend when the router has no match. ok is false when a route
+// matched a non-S3 backend, which the native protocol can't serve.
+func (h *s3Handler) resolveClient(r *http.Request) (client *s3Client, ok bool) {
+	if h.router != nil {
+		if backend, _, _, matched := h.router.Resolve(r); matched {
+			c, isS3 := backend.(*s3Client)
+			return c, isS3
+		}
+	}
+	return h.client, h.client != nil
+}
+
+func isS3APIRequest(r *http.Request) bool {
+	if strings.HasPrefix(r.Header.Get("Authorization"), awsSigningAlgorithm) {
+		return true
+	}
+	for name := range r.Header {
+		if strings.HasPrefix(strings.ToLower(name), "x-amz-") {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *s3Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !isS3APIRequest(r) {
+		h.browser(w, r)
+		return
+	}
+	client, ok := h.resolveClient(r)
+	if !ok {
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented", "the native S3 API requires an s3 backend for this bucket", r.URL.Path)
+		return
+	}
+	if err := verifySigV4(r, h.creds); err != nil {
+		code := "SignatureDoesNotMatch"
+		if errors.Is(err, errContentSHA256Mismatch) {
+			code = "XAmzContentSHA256Mismatch"
+		}
+		writeS3Error(w, http.StatusForbidden, code, err.Error(), r.URL.Path)
+		return
+	}
+	h.serveS3API(w, r, client)
+}
+
+func splitBucketKey(path string) (bucket, key string) {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	bucket = parts[0]
+	if len(parts) == 2 {
+		key = parts[1]
+	}
+	return bucket, key
+}
+
+func (h *s3Handler) serveS3API(w http.ResponseWriter, r *http.Request, client *s3Client) {
+	bucket, key := splitBucketKey(r.URL.Path)
+	if bucket == "" {
+		if r.Method != http.MethodGet {
+			writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "only GET is supported on /", r.URL.Path)
+			return
+		}
+		h.listBuckets(w, r, client)
+		return
+	}
+	if key == "" {
+		if r.Method != http.MethodGet {
+			writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "only GET is supported for bucket listings", r.URL.Path)
+			return
+		}
+		if r.URL.Query().Get("list-type") == "2" {
+			h.listObjectsV2(w, r, client)
+		} else {
+			h.listObjectsV1(w, r, client)
+		}
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		h.getObject(w, r, client, key, false)
+	case http.MethodHead:
+		h.getObject(w, r, client, key, true)
+	case http.MethodPut:
+		h.putObject(w, r, client, key)
+	case http.MethodDelete:
+		h.deleteObject(w, r, client, key)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", fmt.Sprintf("unsupported method %s", r.Method), r.URL.Path)
+	}
+}
+
+func (h *s3Handler) listBuckets(w http.ResponseWriter, r *http.Request, client *s3Client) {
+	writeXML(w, http.StatusOK, ListAllMyBucketsResult{
+		Buckets: []Bucket{{Name: client.bucket}},
+	})
+}
+
+func (h *s3Handler) listObjectsV2(w http.ResponseWriter, r *http.Request, client *s3Client) {
+	q := r.URL.Query()
+	prefix, delimiter, continuationToken := q.Get("prefix"), q.Get("delimiter"), q.Get("continuation-token")
+	maxKeys := parseMaxKeys(q.Get("max-keys"))
+
+	out, err := client.ListObjectsV2(&s3.ListObjectsV2Input{
+		Bucket:            aws.String(client.bucket),
+		Prefix:            aws.String(prefix),
+		Delimiter:         nonEmptyStringPtr(delimiter),
+		ContinuationToken: nonEmptyStringPtr(continuationToken),
+		MaxKeys:           aws.Int64(maxKeys),
+	})
+	if err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error(), r.URL.Path)
+		return
+	}
+
+	result := ListBucketResult{
+		Name:                  client.bucket,
+		Prefix:                prefix,
+		Delimiter:             delimiter,
+		ContinuationToken:     continuationToken,
+		NextContinuationToken: aws.StringValue(out.NextContinuationToken),
+		MaxKeys:               int(maxKeys),
+		KeyCount:              len(out.Contents) + len(out.CommonPrefixes),
+		IsTruncated:           aws.BoolValue(out.IsTruncated),
+		Contents:              objectsFromS3(out.Contents),
+		CommonPrefixes:        commonPrefixesFromS3(out.CommonPrefixes),
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+func (h *s3Handler) listObjectsV1(w http.ResponseWriter, r *http.Request, client *s3Client) {
+	q := r.URL.Query()
+	prefix, delimiter, marker := q.Get("prefix"), q.Get("delimiter"), q.Get("marker")
+	maxKeys := parseMaxKeys(q.Get("max-keys"))
+
+	out, err := client.ListObjects(&s3.ListObjectsInput{
+		Bucket:    aws.String(client.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: nonEmptyStringPtr(delimiter),
+		Marker:    nonEmptyStringPtr(marker),
+		MaxKeys:   aws.Int64(maxKeys),
+	})
+	if err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error(), r.URL.Path)
+		return
+	}
+
+	result := ListBucketResult{
+		Name:           client.bucket,
+		Prefix:         prefix,
+		Delimiter:      delimiter,
+		Marker:         marker,
+		NextMarker:     aws.StringValue(out.NextMarker),
+		MaxKeys:        int(maxKeys),
+		IsTruncated:    aws.BoolValue(out.IsTruncated),
+		Contents:       objectsFromS3(out.Contents),
+		CommonPrefixes: commonPrefixesFromS3(out.CommonPrefixes),
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+func (h *s3Handler) getObject(w http.ResponseWriter, r *http.Request, client *s3Client, key string, headOnly bool) {
+	if headOnly {
+		out, err := client.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(client.bucket), Key: aws.String(key)})
+		if err != nil {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey", err.Error(), r.URL.Path)
+			return
+		}
+		setObjectHeaders(w, aws.StringValue(out.ContentType), aws.Int64Value(out.ContentLength), aws.StringValue(out.ETag), aws.TimeValue(out.LastModified))
+		return
+	}
+	out, err := client.GetObject(&s3.GetObjectInput{Bucket: aws.String(client.bucket), Key: aws.String(key)})
+	if err != nil {
+		writeS3Error(w, http.StatusNotFound, "NoSuchKey", err.Error(), r.URL.Path)
+		return
+	}
+	defer out.Body.Close()
+	setObjectHeaders(w, aws.StringValue(out.ContentType), aws.Int64Value(out.ContentLength), aws.StringValue(out.ETag), aws.TimeValue(out.LastModified))
+	_, _ = io.Copy(w, out.Body)
+}
+
+func setObjectHeaders(w http.ResponseWriter, contentType string, contentLength int64, etag string, lastModified time.Time) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(contentLength, 10))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+}
+
+func (h *s3Handler) putObject(w http.ResponseWriter, r *http.Request, client *s3Client, key string) {
+	if !h.writesEnabled {
+		writeS3Error(w, http.StatusForbidden, "AccessDenied", "writes are disabled on this gateway", r.URL.Path)
+		return
+	}
+	body, ok := verifiedBody(r)
+	if !ok {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "InvalidRequest", err.Error(), r.URL.Path)
+			return
+		}
+	}
+	_, err := client.PutObject(&s3.PutObjectInput{
+		Bucket:        aws.String(client.bucket),
+		Key:           aws.String(key),
+		Body:          bytes.NewReader(body),
+		ContentLength: aws.Int64(int64(len(body))),
+		ContentType:   nonEmptyStringPtr(r.Header.Get("Content-Type")),
+	})
+	if err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error(), r.URL.Path)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *s3Handler) deleteObject(w http.ResponseWriter, r *http.Request, client *s3Client, key string) {
+	if !h.writesEnabled {
+		writeS3Error(w, http.StatusForbidden, "AccessDenied", "writes are disabled on this gateway", r.URL.Path)
+		return
+	}
+	if _, err := client.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String(client.bucket), Key: aws.String(key)}); err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error(), r.URL.Path)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func objectsFromS3(objects []*s3.Object) []Object {
+	result := make([]Object, 0, len(objects))
+	for _, o := range objects {
+		result = append(result, Object{
+			Key:          aws.StringValue(o.Key),
+			LastModified: aws.TimeValue(o.LastModified),
+			Size:         aws.Int64Value(o.Size),
+			StorageClass: aws.StringValue(o.StorageClass),
+		})
+	}
+	return result
+}
+
+func commonPrefixesFromS3(prefixes []*s3.CommonPrefix) []CommonPrefix {
+	result := make([]CommonPrefix, 0, len(prefixes))
+	for _, p := range prefixes {
+		result = append(result, CommonPrefix{Prefix: aws.StringValue(p.Prefix)})
+	}
+	return result
+}
+
+func parseMaxKeys(raw string) int64 {
+	const defaultMaxKeys = 1000
+	if raw == "" {
+		return defaultMaxKeys
+	}
+	parsed, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || parsed <= 0 {
+		return defaultMaxKeys
+	}
+	return parsed
+}
+
+func nonEmptyStringPtr(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code, message, resource string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(s3Error{Code: code, Message: message, Resource: resource})
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+func init() {
+	RegisterBackend("s3", newS3BackendFromURL)
+}
+
+type s3Client struct {
+	s3iface.S3API
+	bucket string
+}
+
+// newS3BackendFromURL builds an s3Client for a "s3://bucket" config string,
+// using the same endpoint/credential environment variables the gateway has
+// always read.
+func newS3BackendFromURL(config *url.URL) (Backend, error) {
+	bucket := config.Host
+	if bucket == "" {
+		return nil, fmt.Errorf("s3 backend requires a bucket, e.g. s3://bucket")
+	}
+	return newS3BackendWithCredentials(bucket, os.Getenv("S3_ENDPOINT"), os.Getenv("S3_ACCESS_KEY_ID"), os.Getenv("S3_SECRET_ACCESS_KEY"))
+}
+
+// newS3BackendWithCredentials builds an s3Client against an explicit
+// endpoint and credentials, so the Router can give each route its own
+// account instead of sharing the gateway's global S3 session.
+func newS3BackendWithCredentials(bucket, endpoint, accessKeyID, secretAccessKey string) (Backend, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(endpoint),
+		Credentials: credentials.NewStaticCredentials(accessKeyID, secretAccessKey, ""),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %v", err)
+	}
+	return &s3Client{S3API: s3.New(sess), bucket: bucket}, nil
+}
+
+func (c *s3Client) List(prefix string) ([]file, error) {
+	var (
+		continuationToken *string
+		files             []file
+		key               = strings.TrimPrefix(prefix, "/")
+	)
+	for {
+		listObjectsV2Output, err := c.S3API.ListObjectsV2(&s3.ListObjectsV2Input{
+			Bucket:            aws.String(c.bucket),
+			Delimiter:         aws.String("/"),
+			Prefix:            aws.String(key),
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range listObjectsV2Output.Contents {
+			files = append(files, file{
+				name:         aws.StringValue(object.Key),
+				size:         aws.Int64Value(object.Size),
+				lastModified: aws.TimeValue(object.LastModified),
+			})
+		}
+		for _, prefix := range listObjectsV2Output.CommonPrefixes {
+			files = append(files, file{
+				name:  aws.StringValue(prefix.Prefix),
+				isDir: true,
+			})
+		}
+		if !aws.BoolValue(listObjectsV2Output.IsTruncated) || listObjectsV2Output.NextContinuationToken == nil {
+			break
+		}
+		continuationToken = listObjectsV2Output.NextContinuationToken
+	}
+	return files, nil
+}
+
+func (c *s3Client) PresignGet(key string, ttl time.Duration) (string, error) {
+	req, _ := c.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	return req.Presign(ttl)
+}
+
+func (c *s3Client) Open(key string) (io.ReadCloser, error) {
+	out, err := c.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(strings.TrimPrefix(key, "/")),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (c *s3Client) Stat(key string) (ObjectInfo, error) {
+	out, err := c.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(strings.TrimPrefix(key, "/")),
+	})
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{
+		ContentType:   aws.StringValue(out.ContentType),
+		ContentLength: aws.Int64Value(out.ContentLength),
+		ETag:          aws.StringValue(out.ETag),
+		LastModified:  aws.TimeValue(out.LastModified),
+	}, nil
+}
+
+// OpenRange passes rangeHeader straight through to S3's GetObject Range
+// parameter, since AWS already speaks the same "bytes=start-end" syntax the
+// gateway receives from clients.
+func (c *s3Client) OpenRange(key, rangeHeader string) (io.ReadCloser, ObjectInfo, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(strings.TrimPrefix(key, "/")),
+	}
+	if rangeHeader != "" {
+		input.Range = aws.String(rangeHeader)
+	}
+	out, err := c.GetObject(input)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	return out.Body, ObjectInfo{
+		ContentType:   aws.StringValue(out.ContentType),
+		ContentLength: aws.Int64Value(out.ContentLength),
+		ContentRange:  aws.StringValue(out.ContentRange),
+		ETag:          aws.StringValue(out.ETag),
+		LastModified:  aws.TimeValue(out.LastModified),
+	}, nil
+}
+
+// uploadPartSizeBytes reads the S3_UPLOAD_PART_SIZE_BYTES override for the
+// multipart uploader, defaulting to the SDK's own default part size.
+func uploadPartSizeBytes() int64 {
+	raw := os.Getenv("S3_UPLOAD_PART_SIZE_BYTES")
+	if raw == "" {
+		return s3manager.DefaultUploadPartSize
+	}
+	parsed, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || parsed < s3manager.MinUploadPartSize {
+		return s3manager.DefaultUploadPartSize
+	}
+	return parsed
+}
+
+// Put uses an s3manager.Uploader so bodies over the part size threshold are
+// split into a multipart upload automatically; the uploader aborts the
+// upload itself if any part fails, so partial objects don't leak.
+func (c *s3Client) Put(key string, data io.Reader, size int64, contentType string) error {
+	uploader := s3manager.NewUploaderWithClient(c.S3API, func(u *s3manager.Uploader) {
+		u.PartSize = uploadPartSizeBytes()
+	})
+	_, err := uploader.Upload(&s3manager.UploadInput{
+		Bucket:      aws.String(c.bucket),
+		Key:         aws.String(strings.TrimPrefix(key, "/")),
+		Body:        data,
+		ContentType: nonEmptyStringPtr(contentType),
+	})
+	return err
+}
+
+func (c *s3Client) Delete(key string) error {
+	_, err := c.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(strings.TrimPrefix(key, "/")),
+	})
+	return err
+}
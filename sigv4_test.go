@@ -0,0 +1,240 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signSigV4Request builds an httptest request for method/rawURL/body, signs
+// it with accessKey/secret over the host, x-amz-content-sha256, and
+// x-amz-date headers, and sets the resulting Authorization header, the way a
+// real S3 SDK would.
+func signSigV4Request(method, rawURL, body, accessKey, secret string) *http.Request {
+	sum := sha256.Sum256([]byte(body))
+	return signSigV4RequestWithHash(method, rawURL, body, hex.EncodeToString(sum[:]), accessKey, secret)
+}
+
+// signSigV4RequestWithHash is signSigV4Request with an explicit declared
+// X-Amz-Content-Sha256, so tests can sign a request declaring
+// "UNSIGNED-PAYLOAD" instead of the body's real hash.
+func signSigV4RequestWithHash(method, rawURL, body, contentSha256, accessKey, secret string) *http.Request {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req := httptest.NewRequest(method, rawURL, strings.NewReader(body))
+	req.Header.Set("X-Amz-Content-Sha256", contentSha256)
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	cred := sigV4Credential{accessKeyID: accessKey, date: date, region: "us-east-1", service: "s3"}
+	hashedCR := sha256.Sum256([]byte(canonicalRequest(req, signedHeaders)))
+	stringToSign := strings.Join([]string{
+		awsSigningAlgorithm, amzDate, cred.scope(), hex.EncodeToString(hashedCR[:]),
+	}, "\n")
+	signature := hex.EncodeToString(hmacSHA256(deriveSigningKey(secret, cred), []byte(stringToSign)))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsSigningAlgorithm, accessKey, cred.scope(), strings.Join(signedHeaders, ";"), signature))
+	return req
+}
+
+func TestVerifySigV4(t *testing.T) {
+	creds := map[string]string{"AKIDEXAMPLE": "secret"}
+
+	t.Run("valid signature", func(t *testing.T) {
+		req := signSigV4Request(http.MethodPut, "http://bucket.example.com/mybucket/mykey", "hello world", "AKIDEXAMPLE", "secret")
+		if err := verifySigV4(req, creds); err != nil {
+			t.Fatalf("expected a validly signed request to verify, got: %v", err)
+		}
+	})
+
+	t.Run("unknown access key", func(t *testing.T) {
+		req := signSigV4Request(http.MethodGet, "http://bucket.example.com/mybucket/", "", "UNKNOWN", "secret")
+		if err := verifySigV4(req, creds); err == nil {
+			t.Fatal("expected an error for an unknown access key id")
+		}
+	})
+
+	t.Run("tampered signature", func(t *testing.T) {
+		req := signSigV4Request(http.MethodGet, "http://bucket.example.com/mybucket/", "", "AKIDEXAMPLE", "secret")
+		req.Header.Set("Authorization", req.Header.Get("Authorization")+"00")
+		if err := verifySigV4(req, creds); err == nil {
+			t.Fatal("expected an error for a tampered signature")
+		}
+	})
+
+	t.Run("stale clock skew", func(t *testing.T) {
+		req := signSigV4Request(http.MethodGet, "http://bucket.example.com/mybucket/", "", "AKIDEXAMPLE", "secret")
+		req.Header.Set("X-Amz-Date", "20200101T000000Z")
+		err := verifySigV4(req, creds)
+		if err == nil || !strings.Contains(err.Error(), "skew") {
+			t.Fatalf("expected a clock skew error, got: %v", err)
+		}
+	})
+
+	t.Run("body swapped after signing", func(t *testing.T) {
+		req := signSigV4Request(http.MethodPut, "http://bucket.example.com/mybucket/mykey", "original body", "AKIDEXAMPLE", "secret")
+		req.Body = io.NopCloser(strings.NewReader("swapped body"))
+		err := verifySigV4(req, creds)
+		if !errors.Is(err, errContentSHA256Mismatch) {
+			t.Fatalf("expected errContentSHA256Mismatch, got: %v", err)
+		}
+	})
+
+	t.Run("unsigned payload skips body check", func(t *testing.T) {
+		req := signSigV4RequestWithHash(http.MethodPut, "http://bucket.example.com/mybucket/mykey", "original body", "UNSIGNED-PAYLOAD", "AKIDEXAMPLE", "secret")
+		req.Body = io.NopCloser(strings.NewReader("anything goes"))
+		if err := verifySigV4(req, creds); err != nil {
+			t.Fatalf("expected UNSIGNED-PAYLOAD to skip the body check, got: %v", err)
+		}
+	})
+}
+
+func TestVerifiedBody(t *testing.T) {
+	req := signSigV4Request(http.MethodPut, "http://bucket.example.com/mybucket/mykey", "hello world", "AKIDEXAMPLE", "secret")
+	if err := verifySigV4(req, map[string]string{"AKIDEXAMPLE": "secret"}); err != nil {
+		t.Fatalf("expected a validly signed request to verify, got: %v", err)
+	}
+	body, ok := verifiedBody(req)
+	if !ok || string(body) != "hello world" {
+		t.Fatalf("expected verifiedBody to return the buffered request body, got (%q, %v)", body, ok)
+	}
+}
+
+func TestParseByteRange(t *testing.T) {
+	const size = 100
+	tests := []struct {
+		name      string
+		header    string
+		wantStart int64
+		wantEnd   int64
+		wantOK    bool
+	}{
+		{"no header", "", 0, 99, false},
+		{"malformed prefix", "nope", 0, 99, false},
+		{"first 10 bytes", "bytes=0-9", 0, 9, true},
+		{"middle range", "bytes=10-19", 10, 19, true},
+		{"open-ended range", "bytes=50-", 50, 99, true},
+		{"suffix range", "bytes=-10", 90, 99, true},
+		{"suffix longer than object", "bytes=-200", 0, 99, true},
+		{"start beyond size", "bytes=200-210", 0, 99, false},
+		{"end before start", "bytes=50-10", 0, 99, false},
+		{"end clamped to size", "bytes=90-1000", 90, 99, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, ok := parseByteRange(tt.header, size)
+			if start != tt.wantStart || end != tt.wantEnd || ok != tt.wantOK {
+				t.Fatalf("parseByteRange(%q, %d) = (%d, %d, %v), want (%d, %d, %v)",
+					tt.header, size, start, end, ok, tt.wantStart, tt.wantEnd, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestWriteConfigAuthorized(t *testing.T) {
+	tests := []struct {
+		name string
+		wc   writeConfig
+		req  func() *http.Request
+		want bool
+	}{
+		{
+			name: "writes disabled",
+			wc:   writeConfig{enabled: false, token: "secret"},
+			req: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPut, "http://gateway/key", nil)
+				r.Header.Set("Authorization", "Bearer secret")
+				return r
+			},
+			want: false,
+		},
+		{
+			name: "valid bearer token",
+			wc:   writeConfig{enabled: true, token: "secret"},
+			req: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPut, "http://gateway/key", nil)
+				r.Header.Set("Authorization", "Bearer secret")
+				return r
+			},
+			want: true,
+		},
+		{
+			name: "wrong bearer token",
+			wc:   writeConfig{enabled: true, token: "secret"},
+			req: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPut, "http://gateway/key", nil)
+				r.Header.Set("Authorization", "Bearer wrong")
+				return r
+			},
+			want: false,
+		},
+		{
+			name: "valid basic auth",
+			wc:   writeConfig{enabled: true, username: "admin", password: "hunter2"},
+			req: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPut, "http://gateway/key", nil)
+				r.SetBasicAuth("admin", "hunter2")
+				return r
+			},
+			want: true,
+		},
+		{
+			name: "wrong basic auth password",
+			wc:   writeConfig{enabled: true, username: "admin", password: "hunter2"},
+			req: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPut, "http://gateway/key", nil)
+				r.SetBasicAuth("admin", "wrong")
+				return r
+			},
+			want: false,
+		},
+		{
+			name: "no credentials configured denies everything",
+			wc:   writeConfig{enabled: true},
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodPut, "http://gateway/key", nil)
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.wc.authorized(tt.req()); got != tt.want {
+				t.Fatalf("authorized() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLoadWriteConfigFailsClosedWithoutPassword re-execs the test binary to
+// exercise loadWriteConfig's log.Fatal guard: ENABLE_WRITES with a username
+// but no password must refuse to start rather than silently accepting an
+// empty password (constantTimeEqual("", "") is true).
+func TestLoadWriteConfigFailsClosedWithoutPassword(t *testing.T) {
+	if os.Getenv("GATEWAY_TEST_SUBPROCESS") == "1" {
+		loadWriteConfig()
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=TestLoadWriteConfigFailsClosedWithoutPassword")
+	cmd.Env = append(os.Environ(),
+		"GATEWAY_TEST_SUBPROCESS=1",
+		"ENABLE_WRITES=true",
+		"WRITE_AUTH_USERNAME=admin",
+		"WRITE_AUTH_PASSWORD=",
+		"WRITE_AUTH_TOKEN=",
+	)
+	if err := cmd.Run(); err == nil {
+		t.Fatal("expected loadWriteConfig to exit non-zero when ENABLE_WRITES is set with a username but no password")
+	}
+}
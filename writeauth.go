@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+)
+
+// writeConfig gates the browser-facing PUT/POST/DELETE endpoints behind
+// ENABLE_WRITES, plus an optional bearer-token or basic-auth check so write
+// access can be locked down separately from read access.
+type writeConfig struct {
+	enabled  bool
+	token    string
+	username string
+	password string
+}
+
+func loadWriteConfig() writeConfig {
+	wc := writeConfig{
+		enabled:  os.Getenv("ENABLE_WRITES") == "true",
+		token:    os.Getenv("WRITE_AUTH_TOKEN"),
+		username: os.Getenv("WRITE_AUTH_USERNAME"),
+		password: os.Getenv("WRITE_AUTH_PASSWORD"),
+	}
+	if wc.enabled && wc.token == "" && (wc.username == "" || wc.password == "") {
+		log.Fatal("ENABLE_WRITES is set but no WRITE_AUTH_TOKEN, and no WRITE_AUTH_USERNAME/WRITE_AUTH_PASSWORD pair, is configured; refusing to start an unauthenticated read-write gateway")
+	}
+	return wc
+}
+
+// authorized checks wc.token as a bearer token first, then falls back to
+// HTTP Basic auth, comparing both with constant-time equality so the check
+// can't leak the configured secret through response-timing differences.
+// loadWriteConfig refuses to enable writes without one of these configured,
+// so there's no "wide open" fallback case here.
+func (wc writeConfig) authorized(r *http.Request) bool {
+	if !wc.enabled {
+		return false
+	}
+	if wc.token != "" {
+		return constantTimeEqual(r.Header.Get("Authorization"), "Bearer "+wc.token)
+	}
+	user, pass, ok := r.BasicAuth()
+	return ok && constantTimeEqual(user, wc.username) && constantTimeEqual(pass, wc.password)
+}
+
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
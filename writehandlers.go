@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+)
+
+// uploadFormHTML and deleteButtonHTML are appended to the plain-text listing
+// produced by renderFileList, so the write endpoints below stay reachable
+// from a browser without a JS framework; both submit as plain POSTs, with
+// deletes using the common _method=DELETE hidden-field convention since HTML
+// forms can't submit DELETE directly.
+const uploadFormHTML = `
+<form method="POST" enctype="multipart/form-data">
+<input type="file" name="file">
+<input type="submit" value="Upload">
+</form>
+`
+
+func deleteButtonHTML(root, name string) string {
+	action := fmt.Sprintf("%s/%s", root, name)
+	return fmt.Sprintf(` <form style="display:inline" method="POST" action="%s"><input type="hidden" name="_method" value="DELETE"><input type="submit" value="delete"></form>`, html.EscapeString(action))
+}
+
+// requireWriteAuth checks wc.authorized(r) and, if it fails, writes a 401 or
+// 403 and reports false so the caller can bail out. 401 only applies when
+// basic auth is configured, since that's the one scheme browsers will
+// automatically retry after seeing WWW-Authenticate.
+func requireWriteAuth(wc writeConfig, w http.ResponseWriter, r *http.Request) bool {
+	if wc.authorized(r) {
+		return true
+	}
+	if wc.username != "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="object-gateway"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	http.Error(w, "forbidden", http.StatusForbidden)
+	return false
+}
+
+// handlePut serves raw, non-multipart uploads: PUT /key with the object body
+// as the request body.
+func handlePut(backend Backend, path string, writes writeConfig, w http.ResponseWriter, r *http.Request) {
+	if !requireWriteAuth(writes, w, r) {
+		return
+	}
+	if err := backend.Put(path, r.Body, r.ContentLength, r.Header.Get("Content-Type")); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handlePost serves the browser-facing multipart/form-data upload form, plus
+// the _method=DELETE override the delete buttons in renderFileList submit
+// through, since plain HTML forms can only ever POST.
+func handlePost(backend Backend, root, path string, writes writeConfig, w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("_method") == "DELETE" {
+		handleDelete(backend, root, path, writes, w, r)
+		return
+	}
+	if !requireWriteAuth(writes, w, r) {
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	key := path + header.Filename
+	contentType := header.Header.Get("Content-Type")
+	if err := backend.Put(key, file, header.Size, contentType); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, root+path, http.StatusSeeOther)
+}
+
+// handleDelete removes a single object, reached either via a native DELETE
+// request or the _method=DELETE form override, in which case it redirects
+// back to the parent listing instead of returning an empty 204.
+func handleDelete(backend Backend, root, path string, writes writeConfig, w http.ResponseWriter, r *http.Request) {
+	if !requireWriteAuth(writes, w, r) {
+		return
+	}
+	if err := backend.Delete(path); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if r.Method == http.MethodPost {
+		parent := path[:strings.LastIndex(path, "/")+1]
+		http.Redirect(w, r, root+parent, http.StatusSeeOther)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}